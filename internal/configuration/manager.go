@@ -0,0 +1,183 @@
+// Package configuration holds the device's current configuration and
+// exposes it to the rest of the agent through typed getters, so callers
+// don't each have to parse the raw DeviceConfigurationMessage themselves.
+package configuration
+
+import (
+	"time"
+
+	"github.com/jakub-dzon/k4e-operator/models"
+)
+
+// Defaults applied to the data-transfer tunables that aren't themselves
+// carried by DeviceConfigurationMessage.
+const (
+	DefaultDataTransferInterval       = time.Minute
+	DefaultDataTransferMaxInterval    = 15 * time.Minute
+	DefaultDataTransferJitter         = 5 * time.Second
+	DefaultDataTransferDebounceWindow = 5 * time.Second
+	DefaultDataTransferTimeoutTERM    = 10 * time.Second
+	DefaultDataTransferTimeoutKILL    = 30 * time.Second
+	DefaultMaxConcurrentSyncs         = 4
+	DefaultConflictResolution         = "newest-mtime-wins"
+)
+
+// Manager holds the device's current configuration.
+type Manager struct {
+	deviceConfig *models.DeviceConfigurationMessage
+
+	dataTransferInterval       time.Duration
+	dataTransferMaxInterval    time.Duration
+	dataTransferJitter         time.Duration
+	dataTransferDebounceWindow time.Duration
+	dataTransferTimeoutTERM    time.Duration
+	dataTransferTimeoutKILL    time.Duration
+	maxConcurrentSyncs         int
+	conflictResolution         string
+	pruneRemote                bool
+}
+
+// Option configures a Manager created with NewManager, so operators can
+// tune a tunable without the caller having to know all the others.
+type Option func(*Manager)
+
+// WithDataTransferInterval overrides the base interval between scheduled
+// syncs for a workload.
+func WithDataTransferInterval(interval time.Duration) Option {
+	return func(m *Manager) { m.dataTransferInterval = interval }
+}
+
+// WithDataTransferMaxInterval overrides the ceiling scheduled-sync backoff
+// can grow to.
+func WithDataTransferMaxInterval(interval time.Duration) Option {
+	return func(m *Manager) { m.dataTransferMaxInterval = interval }
+}
+
+// WithDataTransferJitter overrides the random jitter added on top of backoff.
+func WithDataTransferJitter(jitter time.Duration) Option {
+	return func(m *Manager) { m.dataTransferJitter = jitter }
+}
+
+// WithDataTransferDebounceWindow overrides how long HandleEvent waits for a
+// workload's state to settle before syncing.
+func WithDataTransferDebounceWindow(window time.Duration) Option {
+	return func(m *Manager) { m.dataTransferDebounceWindow = window }
+}
+
+// WithDataTransferTimeoutTERM overrides how long escalateShutdown waits
+// after cancelling an in-flight sync's context before forcibly aborting its
+// backend.
+func WithDataTransferTimeoutTERM(timeout time.Duration) Option {
+	return func(m *Manager) { m.dataTransferTimeoutTERM = timeout }
+}
+
+// WithDataTransferTimeoutKILL overrides how long escalateShutdown waits
+// after forcibly aborting a backend before abandoning the sync altogether.
+func WithDataTransferTimeoutKILL(timeout time.Duration) Option {
+	return func(m *Manager) { m.dataTransferTimeoutKILL = timeout }
+}
+
+// WithMaxConcurrentSyncs overrides the size of the worker pool dispatching
+// concurrent syncs.
+func WithMaxConcurrentSyncs(n int) Option {
+	return func(m *Manager) { m.maxConcurrentSyncs = n }
+}
+
+// WithConflictResolution overrides the strategy bidirectional syncs use to
+// pick a winner when both the local and remote copies changed.
+func WithConflictResolution(resolution string) Option {
+	return func(m *Manager) { m.conflictResolution = resolution }
+}
+
+// WithPruneRemote overrides whether an upload DataPath deletes remote files
+// that disappeared from its source since the last sync.
+func WithPruneRemote(prune bool) Option {
+	return func(m *Manager) { m.pruneRemote = prune }
+}
+
+// NewManager creates a Manager for deviceConfig, applying this package's
+// defaults for the tunables opts doesn't override.
+func NewManager(deviceConfig *models.DeviceConfigurationMessage, opts ...Option) *Manager {
+	m := &Manager{
+		deviceConfig:               deviceConfig,
+		dataTransferInterval:       DefaultDataTransferInterval,
+		dataTransferMaxInterval:    DefaultDataTransferMaxInterval,
+		dataTransferJitter:         DefaultDataTransferJitter,
+		dataTransferDebounceWindow: DefaultDataTransferDebounceWindow,
+		dataTransferTimeoutTERM:    DefaultDataTransferTimeoutTERM,
+		dataTransferTimeoutKILL:    DefaultDataTransferTimeoutKILL,
+		maxConcurrentSyncs:         DefaultMaxConcurrentSyncs,
+		conflictResolution:         DefaultConflictResolution,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// GetDeviceConfiguration returns the device's current configuration message.
+func (m *Manager) GetDeviceConfiguration() *models.DeviceConfigurationMessage {
+	return m.deviceConfig
+}
+
+// GetWorkloads returns the workloads assigned to this device.
+func (m *Manager) GetWorkloads() []*models.WorkloadData {
+	if m.deviceConfig == nil {
+		return nil
+	}
+	return m.deviceConfig.Workloads
+}
+
+// GetDataTransferInterval returns the base interval between scheduled syncs
+// for a workload.
+func (m *Manager) GetDataTransferInterval() time.Duration {
+	return m.dataTransferInterval
+}
+
+// GetDataTransferMaxInterval returns the ceiling scheduled-sync backoff can
+// grow to.
+func (m *Manager) GetDataTransferMaxInterval() time.Duration {
+	return m.dataTransferMaxInterval
+}
+
+// GetDataTransferJitter returns the random jitter added on top of backoff.
+func (m *Manager) GetDataTransferJitter() time.Duration {
+	return m.dataTransferJitter
+}
+
+// GetDataTransferDebounceWindow returns how long HandleEvent waits for a
+// workload's state to settle before syncing.
+func (m *Manager) GetDataTransferDebounceWindow() time.Duration {
+	return m.dataTransferDebounceWindow
+}
+
+// GetDataTransferTimeoutTERM returns how long escalateShutdown waits after
+// cancelling an in-flight sync's context before forcibly aborting its
+// backend.
+func (m *Manager) GetDataTransferTimeoutTERM() time.Duration {
+	return m.dataTransferTimeoutTERM
+}
+
+// GetDataTransferTimeoutKILL returns how long escalateShutdown waits after
+// forcibly aborting a backend before abandoning the sync altogether.
+func (m *Manager) GetDataTransferTimeoutKILL() time.Duration {
+	return m.dataTransferTimeoutKILL
+}
+
+// GetMaxConcurrentSyncs returns the size of the worker pool dispatching
+// concurrent syncs.
+func (m *Manager) GetMaxConcurrentSyncs() int {
+	return m.maxConcurrentSyncs
+}
+
+// GetConflictResolution returns the strategy bidirectional syncs use to
+// pick a winner when both the local and remote copies changed.
+func (m *Manager) GetConflictResolution() string {
+	return m.conflictResolution
+}
+
+// GetPruneRemote reports whether an upload DataPath should delete remote
+// files that disappeared from its source since the last sync.
+func (m *Manager) GetPruneRemote() bool {
+	return m.pruneRemote
+}
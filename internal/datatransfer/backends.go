@@ -0,0 +1,88 @@
+package datatransfer
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sync"
+
+	"github.com/jakub-dzon/k4e-device-worker/internal/datatransfer/rsync"
+	"github.com/jakub-dzon/k4e-device-worker/internal/datatransfer/s3"
+	"github.com/jakub-dzon/k4e-operator/models"
+)
+
+func init() {
+	RegisterSyncer("s3", newS3Syncer)
+	RegisterSyncer("rsync", newRsyncSyncer)
+}
+
+func newS3Syncer(storage *models.Storage) (Syncer, error) {
+	s3Config := storage.S3
+	accessKeyBytes, err := base64.StdEncoding.DecodeString(s3Config.AwsAccessKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("can't decode AWS Access Key: %v", err)
+	}
+	secretKeyBytes, err := base64.StdEncoding.DecodeString(s3Config.AwsSecretAccessKey)
+	if err != nil {
+		return nil, fmt.Errorf("can't decode AWS Secret Key: %v", err)
+	}
+	backend := s3.NewSync(s3Config.BucketHost, s3Config.BucketPort, string(accessKeyBytes), string(secretKeyBytes), s3Config.BucketName)
+	return &abortableS3Syncer{backend: backend}, nil
+}
+
+func newRsyncSyncer(storage *models.Storage) (Syncer, error) {
+	if storage.Rsync == nil {
+		return nil, fmt.Errorf("rsync backend selected but storage.Rsync is not configured")
+	}
+	return rsync.NewSync(rsync.WithDestination(storage.Rsync.Destination)), nil
+}
+
+// abortableS3Syncer adapts s3.Sync, which has no notion of a context, to the
+// ctx-aware Syncer interface and makes it Abortable. s3.Sync's own HTTP call
+// can't be interrupted mid-flight since it doesn't accept a context itself,
+// so cancelling ctx (or calling Abort) only unblocks this wrapper's wait;
+// the underlying request keeps running in the background until it completes
+// or the S3 client's own timeout elapses.
+type abortableS3Syncer struct {
+	backend *s3.Sync
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+func (s *abortableS3Syncer) SyncPath(ctx context.Context, source, target string) error {
+	return s.run(ctx, func() error { return s.backend.SyncPath(source, target) })
+}
+
+func (s *abortableS3Syncer) run(ctx context.Context, op func() error) error {
+	callCtx, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	s.cancel = cancel
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.cancel = nil
+		s.mu.Unlock()
+		cancel()
+	}()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- op() }()
+	select {
+	case err := <-errCh:
+		return err
+	case <-callCtx.Done():
+		return callCtx.Err()
+	}
+}
+
+// Abort cancels the context of any SyncPath call currently running on this
+// backend, unblocking escalateShutdown's wait immediately.
+func (s *abortableS3Syncer) Abort() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	return nil
+}
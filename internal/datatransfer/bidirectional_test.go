@@ -0,0 +1,38 @@
+package datatransfer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jakub-dzon/k4e-device-worker/internal/configuration"
+	"github.com/jakub-dzon/k4e-device-worker/internal/workload"
+)
+
+func newTestMonitor(t *testing.T, opts ...configuration.Option) *Monitor {
+	t.Helper()
+	return NewMonitor(workload.NewWorkloadManager(t.TempDir()), configuration.NewManager(nil, opts...), nil)
+}
+
+func TestSyncBidirectionalFileAdoptsRemoteCopyWhenNoLocalCopyExists(t *testing.T) {
+	m := newTestMonitor(t)
+
+	source := filepath.Join(t.TempDir(), "config.json")
+	syncer := &fakeBidirectionalSyncer{}
+
+	if err := m.syncBidirectionalFile(context.Background(), "wl", syncer, syncer, source, "remote/config.json"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatalf("expected the remote copy to be adopted onto disk, got: %v", err)
+	}
+	if string(data) != "remote-data" {
+		t.Fatalf("got content %q, want the pulled remote content", data)
+	}
+	if len(syncer.syncedPaths) != 0 {
+		t.Fatalf("expected no upload attempt for a nonexistent local source, got %v", syncer.syncedPaths)
+	}
+}
@@ -0,0 +1,31 @@
+package datatransfer
+
+import "time"
+
+// ConflictResolution picks a winner when both the local copy and the remote
+// copy of a bidirectionally-synced path have changed since the last
+// successful sync.
+type ConflictResolution string
+
+const (
+	// NewestModTimeWins keeps whichever side was modified most recently. This
+	// is the default strategy.
+	NewestModTimeWins ConflictResolution = "newest-mtime-wins"
+	// LocalWins always prefers the device's copy.
+	LocalWins ConflictResolution = "local-wins"
+	// RemoteWins always prefers the server's copy.
+	RemoteWins ConflictResolution = "remote-wins"
+)
+
+// keepLocal reports whether the local copy should be kept over the remote
+// one given the configured resolution strategy.
+func (c ConflictResolution) keepLocal(localModTime, remoteModTime time.Time) bool {
+	switch c {
+	case LocalWins:
+		return true
+	case RemoteWins:
+		return false
+	default:
+		return localModTime.After(remoteModTime)
+	}
+}
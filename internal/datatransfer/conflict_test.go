@@ -0,0 +1,28 @@
+package datatransfer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConflictResolutionKeepLocal(t *testing.T) {
+	older := time.Now()
+	newer := older.Add(time.Minute)
+
+	cases := []struct {
+		resolution ConflictResolution
+		local      time.Time
+		remote     time.Time
+		want       bool
+	}{
+		{resolution: LocalWins, local: older, remote: newer, want: true},
+		{resolution: RemoteWins, local: newer, remote: older, want: false},
+		{resolution: NewestModTimeWins, local: older, remote: newer, want: false},
+		{resolution: NewestModTimeWins, local: newer, remote: older, want: true},
+	}
+	for _, c := range cases {
+		if got := c.resolution.keepLocal(c.local, c.remote); got != c.want {
+			t.Errorf("%s.keepLocal(%s, %s) = %v, want %v", c.resolution, c.local, c.remote, got, c.want)
+		}
+	}
+}
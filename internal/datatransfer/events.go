@@ -0,0 +1,21 @@
+package datatransfer
+
+import "time"
+
+// ConflictEvent is emitted when a bidirectional sync finds that both the
+// local and remote copies of a path changed since the last successful sync,
+// and a resolution strategy had to pick a winner.
+type ConflictEvent struct {
+	WorkloadName string
+	Source       string
+	Target       string
+	Resolution   ConflictResolution
+	KeptLocal    bool
+	DetectedAt   time.Time
+}
+
+const conflictEventBuffer = 1000
+
+func newConflictEventChannel() chan *ConflictEvent {
+	return make(chan *ConflictEvent, conflictEventBuffer)
+}
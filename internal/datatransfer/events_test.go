@@ -0,0 +1,131 @@
+package datatransfer
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jakub-dzon/k4e-device-worker/internal/configuration"
+	"github.com/jakub-dzon/k4e-device-worker/internal/service"
+	"github.com/jakub-dzon/k4e-device-worker/internal/workload"
+	"github.com/jakub-dzon/k4e-operator/models"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// countingSyncer is a Syncer that reports each SyncPath call on calledCh, so
+// tests can wait for a debounced or scheduled sync to actually fire instead
+// of guessing at a sleep duration.
+type countingSyncer struct {
+	calledCh chan string
+}
+
+func (s *countingSyncer) SyncPath(ctx context.Context, source, target string) error {
+	s.calledCh <- target
+	return nil
+}
+
+func registerFakeRsyncBackend(t *testing.T, syncer Syncer) {
+	t.Helper()
+	original := syncerRegistry["rsync"]
+	syncerRegistry["rsync"] = func(storage *models.Storage) (Syncer, error) { return syncer, nil }
+	t.Cleanup(func() { syncerRegistry["rsync"] = original })
+}
+
+func newEventTestMonitor(t *testing.T, hostPath string, debounceWindow time.Duration) (*Monitor, *workload.WorkloadManager) {
+	t.Helper()
+	deviceConfig := &models.DeviceConfigurationMessage{
+		Storage: &models.Storage{Rsync: &models.RsyncStorage{}},
+		Workloads: []*models.WorkloadData{
+			{
+				Name: "wl",
+				Data: &models.DataConfiguration{
+					Paths: []*models.DataPath{{Source: "", Target: "remote"}},
+				},
+			},
+		},
+	}
+	config := configuration.NewManager(deviceConfig, configuration.WithDataTransferDebounceWindow(debounceWindow))
+	workloads := workload.NewWorkloadManager(t.TempDir())
+	workloads.AddWorkload("wl", hostPath)
+	return NewMonitor(workloads, config, nil), workloads
+}
+
+// waitForSync waits for a sync on calledCh, failing the test if it doesn't
+// fire within a generous multiple of the debounce window.
+func waitForSync(t *testing.T, calledCh chan string, timeout time.Duration) {
+	t.Helper()
+	select {
+	case <-calledCh:
+	case <-time.After(timeout):
+		t.Fatalf("expected a sync to fire within %s, none did", timeout)
+	}
+}
+
+func assertNoSync(t *testing.T, calledCh chan string, wait time.Duration) {
+	t.Helper()
+	select {
+	case target := <-calledCh:
+		t.Fatalf("expected no sync, got one for target %q", target)
+	case <-time.After(wait):
+	}
+}
+
+func TestHandleEventCoalescesAFlurryOfTransitionsIntoOneSync(t *testing.T) {
+	debounce := 20 * time.Millisecond
+	// os.Stat on the host path must succeed for syncUpload to reach
+	// SyncPath; write it as a plain file so the single-file upload path
+	// (not the manifest/directory one) is exercised.
+	hostPath := t.TempDir() + "/hostfile"
+	writeTestFile(t, hostPath, "data")
+	m, _ := newEventTestMonitor(t, hostPath, debounce)
+
+	syncer := &countingSyncer{calledCh: make(chan string, 10)}
+	registerFakeRsyncBackend(t, syncer)
+
+	for i := 0; i < 5; i++ {
+		m.HandleEvent(&service.Event{WorkloadName: "wl", Type: service.EventStarted})
+		time.Sleep(debounce / 4)
+	}
+
+	waitForSync(t, syncer.calledCh, debounce*10)
+	assertNoSync(t, syncer.calledCh, debounce*5)
+}
+
+func TestHandleEventFlushesOnEventStoppedEvenAfterTheWorkloadIsGone(t *testing.T) {
+	debounce := 20 * time.Millisecond
+	hostPath := t.TempDir() + "/hostfile"
+	writeTestFile(t, hostPath, "data")
+	m, workloads := newEventTestMonitor(t, hostPath, debounce)
+
+	syncer := &countingSyncer{calledCh: make(chan string, 10)}
+	registerFakeRsyncBackend(t, syncer)
+
+	// By the time the debounce window elapses the workload may already be
+	// gone from ListWorkloads(); flushStoppedWorkload must still run.
+	workloads.RemoveWorkload("wl")
+	m.HandleEvent(&service.Event{WorkloadName: "wl", Type: service.EventStopped})
+
+	waitForSync(t, syncer.calledCh, debounce*10)
+}
+
+func TestHandleEventSkipsSyncOnEventStartedWhenTheWorkloadIsGone(t *testing.T) {
+	debounce := 20 * time.Millisecond
+	hostPath := t.TempDir() + "/hostfile"
+	writeTestFile(t, hostPath, "data")
+	m, workloads := newEventTestMonitor(t, hostPath, debounce)
+
+	syncer := &countingSyncer{calledCh: make(chan string, 10)}
+	registerFakeRsyncBackend(t, syncer)
+
+	workloads.RemoveWorkload("wl")
+	m.HandleEvent(&service.Event{WorkloadName: "wl", Type: service.EventStarted})
+
+	assertNoSync(t, syncer.calledCh, debounce*10)
+}
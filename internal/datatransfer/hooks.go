@@ -0,0 +1,41 @@
+package datatransfer
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+)
+
+const defaultHookTimeout = 30 * time.Second
+
+// runHook executes a pre/post sync command as the workload's systemd user,
+// capturing its output for diagnostics. An empty command is a no-op.
+func runHook(workloadName, command string, timeout time.Duration) error {
+	if command == "" {
+		return nil
+	}
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "systemd-run", "--user", "--pipe", "--wait", "--collect", "--", "/bin/sh", "-c", command)
+	output, err := cmd.CombinedOutput()
+	trimmed := strings.TrimSpace(string(output))
+	if trimmed != "" {
+		log.Debugf("Sync hook for workload %s produced output: %s", workloadName, trimmed)
+	}
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("sync hook for workload %s timed out after %s", workloadName, timeout)
+		}
+		return fmt.Errorf("sync hook for workload %s failed: %s: %w", workloadName, trimmed, err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package datatransfer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// fileRecord is what the manifest remembers about a file as of its last
+// successful upload, enough to tell whether it needs re-uploading without
+// re-hashing files that haven't changed on disk.
+type fileRecord struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	SHA256  string    `json:"sha256"`
+}
+
+// manifest is the content-addressed record of what's already been uploaded
+// for a workload's data path, keyed by path relative to the DataPath source.
+type manifest struct {
+	Files map[string]fileRecord `json:"files"`
+}
+
+func newManifest() *manifest {
+	return &manifest{Files: make(map[string]fileRecord)}
+}
+
+// manifestStore persists a manifest as a JSON file under the workload's
+// state directory.
+type manifestStore struct {
+	path string
+}
+
+func newManifestStore(stateDir, dataPathName string) *manifestStore {
+	return &manifestStore{path: filepath.Join(stateDir, dataPathName+".manifest.json")}
+}
+
+func (s *manifestStore) load() (*manifest, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return newManifest(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := newManifest()
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("can't parse manifest %s: %w", s.path, err)
+	}
+	return m, nil
+}
+
+// save persists the manifest atomically: it's written to a temporary file in
+// the same directory and renamed into place, so a sync interrupted mid-write
+// can't leave a corrupt manifest for the next run to trust.
+func (s *manifestStore) save(m *manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.path)
+}
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
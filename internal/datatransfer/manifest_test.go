@@ -0,0 +1,126 @@
+package datatransfer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestManifestStoreSaveLoadRoundTrip(t *testing.T) {
+	store := newManifestStore(t.TempDir(), "datapath")
+
+	m := newManifest()
+	m.Files["a.txt"] = fileRecord{Size: 10, ModTime: time.Now().Truncate(time.Second), SHA256: "abc123"}
+	if err := store.save(m); err != nil {
+		t.Fatalf("unexpected error saving manifest: %v", err)
+	}
+
+	loaded, err := store.load()
+	if err != nil {
+		t.Fatalf("unexpected error loading manifest: %v", err)
+	}
+	if loaded.Files["a.txt"].SHA256 != "abc123" {
+		t.Fatalf("got record %+v, want SHA256 abc123", loaded.Files["a.txt"])
+	}
+}
+
+func TestManifestStoreLoadWithNoFileOnDiskReturnsEmptyManifest(t *testing.T) {
+	store := newManifestStore(t.TempDir(), "never-saved")
+
+	m, err := store.load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(m.Files) != 0 {
+		t.Fatalf("expected an empty manifest, got %d files", len(m.Files))
+	}
+}
+
+func TestSyncFileIfChangedSkipsAFileTheManifestAlreadyHasUnchanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sum, err := sha256File(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	prior := fileRecord{Size: fi.Size(), ModTime: fi.ModTime(), SHA256: sum}
+
+	fake := &fakeSyncer{}
+	record, changed, err := syncFileIfChanged(context.Background(), fake, path, "remote/a.txt", prior, fi)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if changed {
+		t.Fatalf("expected an unchanged file not to be re-uploaded")
+	}
+	if len(fake.syncedPaths) != 0 {
+		t.Fatalf("expected no upload, got %v", fake.syncedPaths)
+	}
+	if record.SHA256 != sum {
+		t.Fatalf("got hash %q, want %q", record.SHA256, sum)
+	}
+}
+
+func TestSyncFileIfChangedUploadsANewFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fake := &fakeSyncer{}
+	record, changed, err := syncFileIfChanged(context.Background(), fake, path, "remote/a.txt", fileRecord{}, fi)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !changed {
+		t.Fatalf("expected a file with no prior record to be uploaded")
+	}
+	if len(fake.syncedPaths) != 1 || fake.syncedPaths[0] != path+"=>remote/a.txt" {
+		t.Fatalf("got synced paths %v", fake.syncedPaths)
+	}
+	if record.Size != fi.Size() {
+		t.Fatalf("got size %d, want %d", record.Size, fi.Size())
+	}
+}
+
+func TestSyncFileIfChangedSkipsUploadWhenContentIsIdenticalDespiteNewMtime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sum, err := sha256File(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Same content, but the recorded size/mtime differ so the fast path is
+	// skipped and the file gets re-hashed.
+	prior := fileRecord{Size: fi.Size() + 1, ModTime: fi.ModTime().Add(-time.Hour), SHA256: sum}
+
+	fake := &fakeSyncer{}
+	_, changed, err := syncFileIfChanged(context.Background(), fake, path, "remote/a.txt", prior, fi)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if changed {
+		t.Fatalf("expected no re-upload when the hash is unchanged")
+	}
+	if len(fake.syncedPaths) != 0 {
+		t.Fatalf("expected no upload, got %v", fake.syncedPaths)
+	}
+}
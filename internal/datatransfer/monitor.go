@@ -1,45 +1,175 @@
+// Package datatransfer synchronizes a workload's DataPaths between the
+// device and remote storage, through a pluggable Syncer backend (see
+// syncer.go).
 package datatransfer
 
 import (
-	"encoding/base64"
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+
 	"git.sr.ht/~spc/go-log"
 	"github.com/jakub-dzon/k4e-device-worker/internal/configuration"
-	"github.com/jakub-dzon/k4e-device-worker/internal/datatransfer/s3"
+	"github.com/jakub-dzon/k4e-device-worker/internal/service"
 	"github.com/jakub-dzon/k4e-device-worker/internal/workload"
 	"github.com/jakub-dzon/k4e-operator/models"
 	"path"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
 
+// schedulerResolution is how often the scheduler checks for workloads whose
+// next scheduled sync is due. It bounds how late a sync can fire relative to
+// its computed nextRun, not the sync interval itself.
+const schedulerResolution = time.Second
+
+// workloadSchedule tracks when a workload is next due for a sync and the
+// backoff state driving that decision.
+type workloadSchedule struct {
+	nextRun      time.Time
+	baseInterval time.Duration
+	failures     int
+	// running is set while a sync dispatched for this workload is still in
+	// flight, so a tick that lands before it finishes doesn't dispatch a
+	// second, overlapping sync for the same workload.
+	running bool
+}
+
+// inFlightSync tracks a currently-running sync so it can be escalated
+// through cancellation, forced abort and, ultimately, abandonment if a
+// workload is removed while its sync is still in flight.
+type inFlightSync struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	syncer Syncer
+}
+
 type Monitor struct {
 	workloads                   *workload.WorkloadManager
 	config                      *configuration.Manager
-	ticker                      *time.Ticker
+	schedulerTicker             *time.Ticker
+	schedules                   map[string]*workloadSchedule
+	schedulesLock               sync.Mutex
+	syncSemaphore               chan struct{}
+	stopCh                      chan struct{}
 	lastSuccessfulSyncTimes     map[string]time.Time
 	lastSuccessfulSyncTimesLock sync.RWMutex
+	conflictEventCh             chan *ConflictEvent
+	pendingSyncs                map[string]*pendingSync
+	pendingSyncsLock            sync.Mutex
+	inFlight                    map[string]*inFlightSync
+	inFlightLock                sync.Mutex
 }
 
-func NewMonitor(workloadsManager *workload.WorkloadManager, configManager *configuration.Manager) *Monitor {
-	ticker := time.NewTicker(configManager.GetDataTransferInterval())
+// pendingSync is a debounced sync waiting to fire for a workload. eventType
+// holds the most recent event that (re)armed the timer, so a flurry of
+// start/stop/dead transitions still fires the right kind of sync once
+// things settle rather than whatever type happened to start the debounce.
+type pendingSync struct {
+	timer     *time.Timer
+	eventType service.EventType
+}
+
+func NewMonitor(workloadsManager *workload.WorkloadManager, configManager *configuration.Manager, eventListener *service.DBusEventListener) *Monitor {
 	monitor := Monitor{
 		workloads:               workloadsManager,
 		config:                  configManager,
-		ticker:                  ticker,
+		schedules:               make(map[string]*workloadSchedule),
+		syncSemaphore:           make(chan struct{}, configManager.GetMaxConcurrentSyncs()),
+		stopCh:                  make(chan struct{}),
 		lastSuccessfulSyncTimes: make(map[string]time.Time),
 		lastSuccessfulSyncTimesLock: sync.RWMutex{},
+		conflictEventCh:         newConflictEventChannel(),
+		pendingSyncs:            make(map[string]*pendingSync),
+		inFlight:                make(map[string]*inFlightSync),
+	}
+	if eventListener != nil {
+		go monitor.watchEvents(eventListener.GetEventChannel())
 	}
 	return &monitor
 }
 
+// watchEvents relays workload state transitions from the DBus event listener
+// into debounced syncs, so a service flapping through start/stop/dead
+// doesn't trigger a sync per transition.
+func (m *Monitor) watchEvents(eventCh <-chan *service.Event) {
+	for event := range eventCh {
+		m.HandleEvent(event)
+	}
+}
+
+// HandleEvent arms a debounced sync for the workload named in event, so a
+// service flapping through start/stop/dead within the coalescing window
+// triggers one sync instead of one per transition. Once the window elapses
+// the most recent event's type decides what that sync does: EventStarted
+// runs the normal, liveness-checked sync; EventStopped flushes final data
+// even though the workload may already be gone from ListWorkloads() by
+// then. The periodic ticker remains a safety net alongside this.
+func (m *Monitor) HandleEvent(event *service.Event) {
+	if event == nil {
+		return
+	}
+
+	m.pendingSyncsLock.Lock()
+	defer m.pendingSyncsLock.Unlock()
+
+	if pending, scheduled := m.pendingSyncs[event.WorkloadName]; scheduled {
+		pending.eventType = event.Type
+		pending.timer.Reset(m.config.GetDataTransferDebounceWindow())
+		return
+	}
+
+	pending := &pendingSync{eventType: event.Type}
+	pending.timer = time.AfterFunc(m.config.GetDataTransferDebounceWindow(), func() {
+		m.pendingSyncsLock.Lock()
+		delete(m.pendingSyncs, event.WorkloadName)
+		eventType := pending.eventType
+		m.pendingSyncsLock.Unlock()
+		m.runEventSync(event.WorkloadName, eventType)
+	})
+	m.pendingSyncs[event.WorkloadName] = pending
+}
+
+// runEventSync dispatches the debounced sync armed by HandleEvent according
+// to the settled event type.
+func (m *Monitor) runEventSync(workloadName string, eventType service.EventType) {
+	if eventType == service.EventStopped {
+		m.flushStoppedWorkload(workloadName)
+		return
+	}
+	m.syncWorkloadByName(workloadName)
+}
+
+// GetConflictEventChannel exposes conflicts detected during bidirectional
+// syncs, i.e. paths where both the local and remote copies changed since the
+// last successful sync and a resolution strategy had to pick a winner.
+func (m *Monitor) GetConflictEventChannel() <-chan *ConflictEvent {
+	return m.conflictEventCh
+}
+
 func (m *Monitor) Start() {
+	m.schedulerTicker = time.NewTicker(schedulerResolution)
 	go func() {
-		for range m.ticker.C {
-			m.syncPaths()
+		for {
+			select {
+			case <-m.schedulerTicker.C:
+				m.dispatchDueSyncs()
+			case <-m.stopCh:
+				m.schedulerTicker.Stop()
+				return
+			}
 		}
 	}()
 }
 
+// Stop halts the scheduler loop started by Start.
+func (m *Monitor) Stop() {
+	close(m.stopCh)
+}
+
 func (m *Monitor) GetLastSuccessfulSyncTime(workloadName string) *time.Time {
 	m.lastSuccessfulSyncTimesLock.RLock()
 	defer m.lastSuccessfulSyncTimesLock.RUnlock()
@@ -49,61 +179,582 @@ func (m *Monitor) GetLastSuccessfulSyncTime(workloadName string) *time.Time {
 	return nil
 }
 
+// GetNextScheduledSyncTime returns when a workload's next sync is due, or
+// nil if the workload hasn't been scheduled yet.
+func (m *Monitor) GetNextScheduledSyncTime(workloadName string) *time.Time {
+	m.schedulesLock.Lock()
+	defer m.schedulesLock.Unlock()
+	sched, ok := m.schedules[workloadName]
+	if !ok {
+		return nil
+	}
+	nextRun := sched.nextRun
+	return &nextRun
+}
+
 func (m *Monitor) WorkloadRemoved(workloadName string) {
 	m.lastSuccessfulSyncTimesLock.Lock()
-	defer m.lastSuccessfulSyncTimesLock.Unlock()
 	delete(m.lastSuccessfulSyncTimes, workloadName)
+	m.lastSuccessfulSyncTimesLock.Unlock()
+
+	m.schedulesLock.Lock()
+	delete(m.schedules, workloadName)
+	m.schedulesLock.Unlock()
+
+	go m.escalateShutdown(workloadName)
+}
+
+// escalateShutdown mirrors the sigterm->sigkill->abandon escalation used to
+// stop dispatched workers: cancel gracefully, wait TimeoutTERM for the sync
+// to exit on its own, forcibly abort its backend, then wait TimeoutKILL
+// before giving up and leaving it to run its course.
+func (m *Monitor) escalateShutdown(workloadName string) {
+	m.inFlightLock.Lock()
+	inFlight, ok := m.inFlight[workloadName]
+	m.inFlightLock.Unlock()
+	if !ok {
+		return
+	}
+
+	log.Infof("Cancelling in-flight sync for removed workload %s", workloadName)
+	inFlight.cancel()
+
+	select {
+	case <-inFlight.done:
+		return
+	case <-time.After(m.config.GetDataTransferTimeoutTERM()):
+	}
+
+	abortable, ok := inFlight.syncer.(Abortable)
+	if !ok {
+		log.Warnf("Sync for removed workload %s didn't stop within the graceful timeout and its backend doesn't support forced abort", workloadName)
+		return
+	}
+	log.Warnf("Sync for removed workload %s didn't stop within the graceful timeout, aborting its backend", workloadName)
+	if err := abortable.Abort(); err != nil {
+		log.Errorf("Error aborting backend for workload %s: %v", workloadName, err)
+	}
+
+	select {
+	case <-inFlight.done:
+	case <-time.After(m.config.GetDataTransferTimeoutKILL()):
+		log.Errorf("Abandoning in-flight sync for removed workload %s: backend did not stop after forced abort", workloadName)
+	}
+}
+
+func (m *Monitor) trackInFlight(workloadName string, syncer Syncer, cancel context.CancelFunc, done chan struct{}) {
+	m.inFlightLock.Lock()
+	defer m.inFlightLock.Unlock()
+	m.inFlight[workloadName] = &inFlightSync{cancel: cancel, done: done, syncer: syncer}
 }
-func (m *Monitor) syncPaths() {
+
+func (m *Monitor) untrackInFlight(workloadName string, done chan struct{}) {
+	close(done)
+	m.inFlightLock.Lock()
+	defer m.inFlightLock.Unlock()
+	if current, ok := m.inFlight[workloadName]; ok && current.done == done {
+		delete(m.inFlight, workloadName)
+	}
+}
+
+// dispatchDueSyncs runs a sync for each workload whose schedule says it's
+// due, bounded by the worker pool so a slow backend for one workload can't
+// stall the rest of the fleet.
+func (m *Monitor) dispatchDueSyncs() {
 	workloads, err := m.workloads.ListWorkloads()
 	if err != nil {
 		log.Errorf("Can't get the list of workloads: %v", err)
+		return
+	}
+
+	for _, wd := range workloads {
+		sched, due := m.tryStartSync(wd.Name)
+		if !due {
+			continue
+		}
+		select {
+		case m.syncSemaphore <- struct{}{}:
+			go m.runScheduledSync(wd.Name, sched)
+		default:
+			// Worker pool is saturated; release the schedule so this
+			// workload is reconsidered on a later tick instead of being
+			// stuck "running" with nothing actually dispatched.
+			m.schedulesLock.Lock()
+			sched.running = false
+			m.schedulesLock.Unlock()
+		}
+	}
+}
+
+// tryStartSync returns the schedule for workloadName, creating one due
+// immediately if this is the first time the workload has been seen, and
+// reports whether it's due and not already running. Checking and marking
+// running happen under the same lock so two ticks can never both see a
+// workload as due and dispatch it twice while a sync for it is in flight.
+func (m *Monitor) tryStartSync(workloadName string) (*workloadSchedule, bool) {
+	m.schedulesLock.Lock()
+	defer m.schedulesLock.Unlock()
+	sched, ok := m.schedules[workloadName]
+	if !ok {
+		sched = &workloadSchedule{
+			nextRun:      time.Now(),
+			baseInterval: m.config.GetDataTransferInterval(),
+		}
+		m.schedules[workloadName] = sched
 	}
-	if len(workloads) == 0 {
+	if sched.running || time.Now().Before(sched.nextRun) {
+		return sched, false
+	}
+	sched.running = true
+	return sched, true
+}
+
+func (m *Monitor) runScheduledSync(workloadName string, sched *workloadSchedule) {
+	defer func() { <-m.syncSemaphore }()
+
+	success := m.syncWorkloadByName(workloadName)
+
+	m.schedulesLock.Lock()
+	defer m.schedulesLock.Unlock()
+	sched.running = false
+	if success {
+		sched.failures = 0
+		sched.nextRun = time.Now().Add(sched.baseInterval)
 		return
 	}
+	sched.failures++
+	sched.nextRun = time.Now().Add(m.backoff(sched))
+}
+
+// backoff computes min(baseInterval * 2^failures, maxInterval) plus a random
+// jitter, so a fleet of devices retrying the same failing backend doesn't
+// synchronize its retries.
+func (m *Monitor) backoff(sched *workloadSchedule) time.Duration {
+	delay := computeBackoff(sched.baseInterval, m.config.GetDataTransferMaxInterval(), sched.failures)
+	if jitter := m.config.GetDataTransferJitter(); jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(jitter)))
+	}
+	return delay
+}
+
+// computeBackoff returns min(baseInterval * 2^failures, maxInterval).
+// baseInterval is compared against maxInterval shifted down by failures
+// before any multiplication, so the product is only computed when it fits
+// under maxInterval and can't overflow time.Duration (an int64 of
+// nanoseconds) and wrap into a negative or too-small delay, which would
+// defeat maxInterval and send a workload into a hot retry loop.
+func computeBackoff(baseInterval, maxInterval time.Duration, failures int) time.Duration {
+	if baseInterval <= 0 {
+		return maxInterval
+	}
+	// Shifting an int64 by 63 or more bits is meaningless; maxInterval
+	// shifted down that far is already zero.
+	const maxShift = 62
+	shift := failures
+	if shift > maxShift {
+		shift = maxShift
+	}
+	if baseInterval > maxInterval>>uint(shift) {
+		return maxInterval
+	}
+	return baseInterval << uint(shift)
+}
+
+// syncWorkloadByName synchronizes a single workload's data paths and reports
+// whether every data path succeeded. It's used both by the scheduler and by
+// EventStarted syncs, both of which only make sense while the workload is
+// actually running.
+func (m *Monitor) syncWorkloadByName(workloadName string) bool {
+	workloads, err := m.workloads.ListWorkloads()
+	if err != nil {
+		log.Errorf("Can't get the list of workloads: %v", err)
+		return false
+	}
+	found := false
+	for _, wd := range workloads {
+		if wd.Name == workloadName {
+			found = true
+			break
+		}
+	}
+	if !found {
+		log.Debugf("Skipping sync for workload %s: no longer running", workloadName)
+		return false
+	}
+
+	return m.runSync(workloadName)
+}
+
+// flushStoppedWorkload performs a final sync for a workload that just
+// stopped, deliberately without checking ListWorkloads(): by the time the
+// debounce window for an EventStopped has elapsed, the workload may already
+// be gone from that list, and skipping the sync on that basis would drop the
+// last data it produced before exiting.
+func (m *Monitor) flushStoppedWorkload(workloadName string) bool {
+	return m.runSync(workloadName)
+}
+
+// runSync builds a syncer from the current configuration and synchronizes
+// workloadName's data paths, tracking it as in-flight so WorkloadRemoved can
+// escalate shutdown against it if needed.
+func (m *Monitor) runSync(workloadName string) bool {
+	syncer, workloadToDataPaths, err := m.prepareSync()
+	if err != nil {
+		log.Errorf("Can't prepare data sync: %v", err)
+		return false
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	m.trackInFlight(workloadName, syncer, cancel, done)
+	defer m.untrackInFlight(workloadName, done)
+
+	return m.syncWorkload(ctx, syncer, workloadName, workloadToDataPaths[workloadName])
+}
+
+func (m *Monitor) prepareSync() (Syncer, map[string][]*models.DataPath, error) {
 	storage := m.config.GetDeviceConfiguration().Storage
-	if storage != nil && storage.S3 != nil {
-		workloadToDataPaths := make(map[string][]*models.DataPath)
-		for _, wd := range m.config.GetWorkloads() {
-			if wd.Data != nil && len(wd.Data.Paths) > 0 {
-				workloadToDataPaths[wd.Name] = wd.Data.Paths
-			}
+	if storage == nil {
+		return nil, nil, fmt.Errorf("no storage configuration present")
+	}
+	syncer, err := newSyncer(storage)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't build syncer for configured storage backend: %w", err)
+	}
+
+	workloadToDataPaths := make(map[string][]*models.DataPath)
+	for _, wd := range m.config.GetWorkloads() {
+		if wd.Data != nil && len(wd.Data.Paths) > 0 {
+			workloadToDataPaths[wd.Name] = wd.Data.Paths
 		}
+	}
+	return syncer, workloadToDataPaths, nil
+}
+
+func (m *Monitor) syncWorkload(ctx context.Context, syncer Syncer, workloadName string, dataPaths []*models.DataPath) bool {
+	if len(dataPaths) == 0 {
+		return true
+	}
+	hostPath := m.workloads.GetExportedHostPath(workloadName)
+	success := true
+	for _, dp := range dataPaths {
+		if ctx.Err() != nil {
+			log.Warnf("Abandoning remaining data paths for removed workload %s", workloadName)
+			return false
+		}
+		source := path.Join(hostPath, dp.Source)
+		if err := m.syncDataPath(ctx, workloadName, syncer, source, dp); err != nil {
+			log.Errorf("Error while synchronizing workload %s data path [device]%s <=> [remote]%s: %v", workloadName, source, dp.Target, err)
+			success = false
+		}
+	}
+	if success {
+		m.storeLastUpdateTime(workloadName)
+	}
+	return success
+}
+
+// syncDataPath synchronizes a single DataPath in whichever direction it's
+// configured for, running its pre/post sync hooks around the transfer.
+func (m *Monitor) syncDataPath(ctx context.Context, workloadName string, syncer Syncer, source string, dp *models.DataPath) error {
+	if err := runHook(workloadName, dp.PreSyncCommand, hookTimeout(dp.HookTimeoutSeconds)); err != nil {
+		return err
+	}
+
+	direction := models.DataPathDirection(dp.Direction)
+	if direction == "" {
+		direction = models.DataPathDirectionUpload
+	}
+
+	var err error
+	switch direction {
+	case models.DataPathDirectionUpload:
+		err = m.syncUpload(ctx, workloadName, syncer, source, dp.Target)
+	case models.DataPathDirectionDownload:
+		err = m.pull(ctx, syncer, source, dp.Target)
+	case models.DataPathDirectionBidirectional:
+		err = m.syncBidirectional(ctx, workloadName, syncer, source, dp.Target)
+	default:
+		err = fmt.Errorf("unknown data path direction %q", dp.Direction)
+	}
+	if err != nil {
+		return err
+	}
+
+	return runHook(workloadName, dp.PostSyncCommand, hookTimeout(dp.HookTimeoutSeconds))
+}
+
+// syncUpload uploads source to target, using a content-addressed manifest to
+// skip files that haven't changed since the last successful upload. Only
+// new or changed files are hashed and re-uploaded; if PruneRemote is
+// enabled and the backend implements Deleter, files that disappeared from
+// source are deleted remotely too (on other backends nothing is deleted).
+// Single-file DataPaths aren't manifest-tracked, since there's nothing to
+// diff against.
+func (m *Monitor) syncUpload(ctx context.Context, workloadName string, syncer Syncer, source, target string) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		log.Infof("Synchronizing [device]%s => [remote]%s", source, target)
+		return syncer.SyncPath(ctx, source, target)
+	}
+
+	store := m.manifestStoreFor(workloadName, target)
+	previous, err := store.load()
+	if err != nil {
+		return fmt.Errorf("can't load sync manifest: %w", err)
+	}
 
-		s3Config := storage.S3
-		accessKeyBytes, err := base64.StdEncoding.DecodeString(s3Config.AwsAccessKeyID)
+	next := newManifest()
+	seen := make(map[string]bool)
+	walkErr := filepath.Walk(source, func(p string, fi os.FileInfo, err error) error {
 		if err != nil {
-			log.Errorf("Can't decode AWS Access Key: %v", err)
+			return err
 		}
-		secretKeyBytes, err := base64.StdEncoding.DecodeString(s3Config.AwsSecretAccessKey)
+		if fi.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(source, p)
 		if err != nil {
-			log.Errorf("Can't decode AWS Access Key: %v", err)
-		}
-		sync := s3.NewSync(s3Config.BucketHost, s3Config.BucketPort, string(accessKeyBytes), string(secretKeyBytes), s3Config.BucketName)
-
-		// Monitor actual workloads and not ones expected by the configuration
-		for _, wd := range workloads {
-			hostPath := m.workloads.GetExportedHostPath(wd.Name)
-			dataPaths := workloadToDataPaths[wd.Name]
-			success := true
-			for _, dp := range dataPaths {
-				source := path.Join(hostPath, dp.Source)
-				target := dp.Target
-
-				log.Infof("Synchronizing [device]%s => [remote]%s", source, target)
-				if err := sync.SyncPath(source, target); err != nil {
-					log.Errorf("Error while synchronizing [device]%s => [remote]%s: %v", source, target, err)
-					success = false
-				}
-			}
-			if success {
-				m.storeLastUpdateTime(wd.Name)
-			}
+			return err
+		}
+		seen[relPath] = true
+
+		record, changed, err := syncFileIfChanged(ctx, syncer, p, path.Join(target, filepath.ToSlash(relPath)), previous.Files[relPath], fi)
+		if err != nil {
+			return fmt.Errorf("can't sync %s: %w", relPath, err)
+		}
+		if changed {
+			log.Debugf("Uploaded changed file %s for workload %s", relPath, workloadName)
+		}
+		next.Files[relPath] = record
+		return nil
+	})
+	if walkErr != nil {
+		return walkErr
+	}
+
+	if m.config.GetPruneRemote() {
+		m.pruneRemoved(syncer, target, previous, seen)
+	}
+
+	return store.save(next)
+}
+
+// syncFileIfChanged re-hashes p if its size or mtime differ from what the
+// manifest last recorded and uploads it only if that hash differs from the
+// recorded one; files whose size and mtime match reuse the recorded hash, so
+// unchanged files are never re-hashed or re-uploaded. It's a free function, not a
+// Monitor method, since it only needs what's passed in.
+func syncFileIfChanged(ctx context.Context, syncer Syncer, p, remoteTarget string, prior fileRecord, fi os.FileInfo) (fileRecord, bool, error) {
+	unchanged := prior.Size == fi.Size() && prior.ModTime.Equal(fi.ModTime()) && prior.SHA256 != ""
+	if unchanged {
+		return prior, false, nil
+	}
+
+	sum, err := sha256File(p)
+	if err != nil {
+		return fileRecord{}, false, err
+	}
+	if sum == prior.SHA256 {
+		return fileRecord{Size: fi.Size(), ModTime: fi.ModTime(), SHA256: sum}, false, nil
+	}
+
+	if err := syncer.SyncPath(ctx, p, remoteTarget); err != nil {
+		return fileRecord{}, false, err
+	}
+	return fileRecord{Size: fi.Size(), ModTime: fi.ModTime(), SHA256: sum}, true, nil
+}
+
+func (m *Monitor) pruneRemoved(syncer Syncer, target string, previous *manifest, seen map[string]bool) {
+	deleter, ok := syncer.(Deleter)
+	if !ok {
+		return
+	}
+	for relPath := range previous.Files {
+		if seen[relPath] {
+			continue
+		}
+		remoteTarget := path.Join(target, filepath.ToSlash(relPath))
+		log.Infof("Deleting [remote]%s: no longer present on device", remoteTarget)
+		if err := deleter.DeletePath(remoteTarget); err != nil {
+			log.Errorf("Can't delete removed remote path %s: %v", remoteTarget, err)
 		}
 	}
 }
 
+func (m *Monitor) manifestStoreFor(workloadName, target string) *manifestStore {
+	stateDir := m.workloads.GetWorkloadStateDir(workloadName)
+	return newManifestStore(stateDir, manifestName(target))
+}
+
+// manifestName derives a filesystem-safe manifest file name from a DataPath
+// target, so a workload with several data paths gets one manifest per path.
+func manifestName(target string) string {
+	replacer := strings.NewReplacer("/", "_", string(filepath.Separator), "_")
+	name := replacer.Replace(strings.Trim(target, "/"))
+	if name == "" {
+		name = "root"
+	}
+	return name
+}
+
+func (m *Monitor) pull(ctx context.Context, syncer Syncer, source, target string) error {
+	downloader, ok := syncer.(Downloader)
+	if !ok {
+		return fmt.Errorf("configured storage backend doesn't support downloading data")
+	}
+	log.Infof("Synchronizing [remote]%s => [device]%s", target, source)
+	return downloader.Pull(ctx, source, target)
+}
+
+// syncBidirectional pulls the remote copy of source into a staging location
+// and, for each file where both the local and remote copies changed since
+// the last successful sync, resolves the conflict per the configured
+// strategy before pushing the winning copies back out. Single-file and
+// directory DataPaths are handled separately, since a directory's own mtime
+// doesn't reflect changes to the files inside it and an os.Rename over an
+// existing non-empty directory would fail outright.
+func (m *Monitor) syncBidirectional(ctx context.Context, workloadName string, syncer Syncer, source, target string) error {
+	downloader, ok := syncer.(Downloader)
+	if !ok {
+		return fmt.Errorf("configured storage backend doesn't support bidirectional sync")
+	}
+
+	if info, err := os.Stat(source); err == nil && info.IsDir() {
+		return m.syncBidirectionalDir(ctx, workloadName, syncer, downloader, source, target)
+	}
+	return m.syncBidirectionalFile(ctx, workloadName, syncer, downloader, source, target)
+}
+
+func (m *Monitor) syncBidirectionalFile(ctx context.Context, workloadName string, syncer Syncer, downloader Downloader, source, target string) error {
+	localInfo, localErr := os.Stat(source)
+	staging := source + ".remote"
+	if err := downloader.Pull(ctx, staging, target); err != nil {
+		return fmt.Errorf("can't pull remote copy for conflict detection: %w", err)
+	}
+	defer os.Remove(staging)
+	remoteInfo, err := os.Stat(staging)
+	if err != nil {
+		return fmt.Errorf("can't stat pulled copy: %w", err)
+	}
+
+	// No local copy exists yet, so there's nothing to keep: always adopt the
+	// remote one. Mirrors syncBidirectionalDir, where an absent local file
+	// takes the remote copy rather than falling into the upload path below.
+	resolution := ConflictResolution(m.config.GetConflictResolution())
+	keepLocal := localErr == nil && resolution.keepLocal(localInfo.ModTime(), remoteInfo.ModTime())
+
+	lastSync := m.GetLastSuccessfulSyncTime(workloadName)
+	conflict := lastSync != nil && localErr == nil &&
+		localInfo.ModTime().After(*lastSync) && remoteInfo.ModTime().After(*lastSync)
+	if lastSync != nil && !conflict {
+		// At most one side changed since the last sync, so there's no
+		// conflict to resolve: keep the local copy unless the remote changed.
+		keepLocal = localErr == nil && !remoteInfo.ModTime().After(*lastSync)
+	}
+	if conflict {
+		m.emitConflictEvent(workloadName, source, target, resolution, keepLocal)
+	}
+
+	if keepLocal {
+		log.Infof("Synchronizing [device]%s => [remote]%s", source, target)
+		return syncer.SyncPath(ctx, source, target)
+	}
+	log.Infof("Synchronizing [remote]%s => [device]%s", target, source)
+	return os.Rename(staging, source)
+}
+
+// syncBidirectionalDir pulls the remote directory into a staging directory,
+// merges each file into source (taking whichever side changed since the last
+// successful sync, and applying the configured resolution strategy when both
+// did or no sync has succeeded yet, comparing the file's own mtime, not the
+// directory's), then pushes the
+// merged directory back out so files that only changed locally reach the
+// remote side too.
+func (m *Monitor) syncBidirectionalDir(ctx context.Context, workloadName string, syncer Syncer, downloader Downloader, source, target string) error {
+	staging, err := os.MkdirTemp(filepath.Dir(source), filepath.Base(source)+".remote-*")
+	if err != nil {
+		return fmt.Errorf("can't create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	if err := downloader.Pull(ctx, staging, target); err != nil {
+		return fmt.Errorf("can't pull remote copy for conflict detection: %w", err)
+	}
+
+	resolution := ConflictResolution(m.config.GetConflictResolution())
+	lastSync := m.GetLastSuccessfulSyncTime(workloadName)
+
+	walkErr := filepath.Walk(staging, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(staging, p)
+		if err != nil {
+			return err
+		}
+		localPath := filepath.Join(source, relPath)
+		localInfo, localErr := os.Stat(localPath)
+		keepLocal := localErr == nil && resolution.keepLocal(localInfo.ModTime(), fi.ModTime())
+
+		conflict := lastSync != nil && localErr == nil && localInfo.ModTime().After(*lastSync) && fi.ModTime().After(*lastSync)
+		if lastSync != nil && !conflict {
+			// Only one side (or neither) changed: keep local unless the remote changed.
+			keepLocal = localErr == nil && !fi.ModTime().After(*lastSync)
+		}
+		if conflict {
+			m.emitConflictEvent(workloadName, localPath, path.Join(target, filepath.ToSlash(relPath)), resolution, keepLocal)
+		}
+		if keepLocal {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(localPath), 0o755); err != nil {
+			return err
+		}
+		return os.Rename(p, localPath)
+	})
+	if walkErr != nil {
+		return walkErr
+	}
+
+	log.Infof("Synchronizing [device]%s => [remote]%s", source, target)
+	return syncer.SyncPath(ctx, source, target)
+}
+
+// emitConflictEvent reports a resolved conflict to GetConflictEventChannel's
+// consumer without blocking the sync: the channel is buffered, but has no
+// guaranteed consumer, and a full buffer would otherwise wedge this
+// worker-pool slot forever waiting on a send nobody drains.
+func (m *Monitor) emitConflictEvent(workloadName, source, target string, resolution ConflictResolution, keptLocal bool) {
+	event := &ConflictEvent{
+		WorkloadName: workloadName,
+		Source:       source,
+		Target:       target,
+		Resolution:   resolution,
+		KeptLocal:    keptLocal,
+		DetectedAt:   time.Now(),
+	}
+	select {
+	case m.conflictEventCh <- event:
+	default:
+		log.Warnf("Dropping conflict event for workload %s path %s: no consumer draining GetConflictEventChannel()", workloadName, source)
+	}
+}
+
+func hookTimeout(seconds int64) time.Duration {
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
 func (m *Monitor) storeLastUpdateTime(workloadName string) {
 	m.lastSuccessfulSyncTimesLock.Lock()
 	defer m.lastSuccessfulSyncTimesLock.Unlock()
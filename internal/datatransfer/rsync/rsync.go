@@ -0,0 +1,166 @@
+package rsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+)
+
+const (
+	defaultTimeout    = 30 * time.Second
+	defaultMaxRetries = 3
+
+	// exitCodeVanishedSourceFiles is the rsync exit code for "some files vanished
+	// before they could be transferred". On a live workload filesystem this is
+	// expected churn rather than a real failure, so it's treated as a warning.
+	exitCodeVanishedSourceFiles = 24
+)
+
+// retryableExitCodes are rsync exit codes that typically indicate a
+// transient network or I/O hiccup, worth retrying. Exit codes not in this
+// set (e.g. 1 syntax/usage error, 2 protocol incompatibility, 3 file
+// selection error, 23 partial transfer due to error) reflect a
+// misconfiguration or permissions problem that retrying won't fix, so
+// sync returns on the first occurrence instead of burning the retry budget.
+var retryableExitCodes = map[int]bool{
+	10: true, // error in socket I/O
+	11: true, // error in file I/O
+	12: true, // error in rsync protocol data stream
+	30: true, // timeout in data send/receive
+	35: true, // timeout waiting for daemon connection
+}
+
+// Sync is a Syncer backend that ships data by shelling out to the rsync binary.
+type Sync struct {
+	timeout     time.Duration
+	maxRetries  int
+	destination string
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+// Option configures a Sync created with NewSync.
+type Option func(*Sync)
+
+// WithTimeout overrides the per-call timeout applied to each rsync invocation.
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Sync) {
+		s.timeout = timeout
+	}
+}
+
+// WithMaxRetries overrides the number of retries attempted on transient failures.
+func WithMaxRetries(maxRetries int) Option {
+	return func(s *Sync) {
+		s.maxRetries = maxRetries
+	}
+}
+
+// WithDestination sets the remote-side prefix (e.g. "user@host:") joined
+// onto a DataPath's target before it's passed to rsync, so the backend
+// actually ships data to the configured remote host rather than to a path
+// on the local filesystem.
+func WithDestination(destination string) Option {
+	return func(s *Sync) {
+		s.destination = destination
+	}
+}
+
+// NewSync creates an rsync-backed Syncer.
+func NewSync(opts ...Option) *Sync {
+	s := &Sync{
+		timeout:    defaultTimeout,
+		maxRetries: defaultMaxRetries,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// SyncPath copies source to target using `rsync -a`, retrying on transient
+// failures up to maxRetries times. target is resolved against the
+// configured destination, if any, so it's reached on the remote host rather
+// than treated as another local path. An exit code of 24 (vanished source
+// files) is treated as a non-fatal warning rather than an error. Cancelling
+// ctx kills the rsync child process currently in flight for this call.
+func (s *Sync) SyncPath(ctx context.Context, source, target string) error {
+	return s.sync(ctx, source, s.destination+target)
+}
+
+// Pull copies target to source using `rsync -a`, i.e. the reverse of
+// SyncPath. It shares the same retry, exit-code and cancellation handling,
+// and is used to bring down data for download and bidirectional DataPaths.
+func (s *Sync) Pull(ctx context.Context, source, target string) error {
+	return s.sync(ctx, s.destination+target, source)
+}
+
+func (s *Sync) sync(ctx context.Context, from, to string) error {
+	var lastErr error
+	for attempt := 0; attempt <= s.maxRetries; attempt++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if attempt > 0 {
+			log.Warnf("Retrying rsync of %s => %s (attempt %d/%d) after: %v", from, to, attempt, s.maxRetries, lastErr)
+		}
+
+		output, err := s.runOnce(ctx, from, to)
+		if err == nil {
+			return nil
+		}
+
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			if exitErr.ExitCode() == exitCodeVanishedSourceFiles {
+				log.Warnf("rsync reported vanished source files while syncing %s => %s, treating as non-fatal", from, to)
+				return nil
+			}
+			if !retryableExitCodes[exitErr.ExitCode()] {
+				return fmt.Errorf("%s: %w", strings.TrimSpace(string(output)), err)
+			}
+		}
+
+		lastErr = fmt.Errorf("%s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return fmt.Errorf("rsync of %s => %s failed after %d attempts: %w", from, to, s.maxRetries+1, lastErr)
+}
+
+func (s *Sync) runOnce(ctx context.Context, source, target string) ([]byte, error) {
+	callCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	s.mu.Lock()
+	s.cancel = cancel
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.cancel = nil
+		s.mu.Unlock()
+		cancel()
+	}()
+
+	cmd := exec.CommandContext(callCtx, "rsync", "-a", source, target)
+	output, err := cmd.CombinedOutput()
+	if err != nil && callCtx.Err() == context.DeadlineExceeded {
+		return output, fmt.Errorf("rsync timed out after %s", s.timeout)
+	}
+	return output, err
+}
+
+// Abort forcibly kills any rsync invocation currently in flight on this
+// Sync, used when a workload is removed while a sync to it is still
+// running and hasn't stopped on its own within the graceful timeout.
+func (s *Sync) Abort() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package datatransfer
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jakub-dzon/k4e-device-worker/internal/configuration"
+)
+
+func TestComputeBackoffDoublesUntilTheMaxInterval(t *testing.T) {
+	base := time.Second
+	max := 10 * time.Minute
+	cases := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 0, want: time.Second},
+		{failures: 1, want: 2 * time.Second},
+		{failures: 2, want: 4 * time.Second},
+		{failures: 10, want: max}, // 2^10s = ~17min, already above max
+	}
+	for _, c := range cases {
+		if got := computeBackoff(base, max, c.failures); got != c.want {
+			t.Errorf("computeBackoff(%s, %s, %d) = %s, want %s", base, max, c.failures, got, c.want)
+		}
+	}
+}
+
+func TestComputeBackoffClampsInsteadOfOverflowing(t *testing.T) {
+	max := time.Minute
+	got := computeBackoff(time.Second, max, 1000)
+	if got != max {
+		t.Fatalf("expected an overflowing failure count to clamp to maxInterval %s, got %s", max, got)
+	}
+	if got <= 0 {
+		t.Fatalf("backoff must never be zero or negative, got %s", got)
+	}
+}
+
+// TestTryStartSyncPreventsOverlappingDispatchUnderConcurrentTicks simulates
+// several scheduler ticks landing for the same workload while it's already
+// due, which is exactly what happens if dispatchDueSyncs runs again before
+// the previous tick's sync for that workload has finished. Exactly one of
+// them must see the workload as due; the rest must see it as already
+// running.
+func TestTryStartSyncPreventsOverlappingDispatchUnderConcurrentTicks(t *testing.T) {
+	m := &Monitor{
+		schedules: make(map[string]*workloadSchedule),
+		config:    configuration.NewManager(nil),
+	}
+
+	const attempts = 50
+	var wg sync.WaitGroup
+	var dueCount int32
+	var mu sync.Mutex
+
+	start := make(chan struct{})
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if _, due := m.tryStartSync("wl"); due {
+				mu.Lock()
+				dueCount++
+				mu.Unlock()
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if dueCount != 1 {
+		t.Fatalf("expected exactly one concurrent tryStartSync call to win, got %d", dueCount)
+	}
+}
+
+// TestTryStartSyncAllowsRedispatchOnceTheRunningSyncClears verifies the
+// schedule's running flag, once cleared (as runScheduledSync does when a
+// sync finishes), lets a later tick dispatch the workload again.
+func TestTryStartSyncAllowsRedispatchOnceTheRunningSyncClears(t *testing.T) {
+	m := &Monitor{
+		schedules: make(map[string]*workloadSchedule),
+		config:    configuration.NewManager(nil),
+	}
+
+	sched, due := m.tryStartSync("wl")
+	if !due {
+		t.Fatalf("expected the first tick to dispatch")
+	}
+	if _, due := m.tryStartSync("wl"); due {
+		t.Fatalf("expected a tick while the sync is running to be skipped")
+	}
+
+	m.schedulesLock.Lock()
+	sched.running = false
+	sched.nextRun = time.Now()
+	m.schedulesLock.Unlock()
+
+	if _, due := m.tryStartSync("wl"); !due {
+		t.Fatalf("expected a tick after the sync cleared to dispatch again")
+	}
+}
@@ -0,0 +1,137 @@
+package datatransfer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jakub-dzon/k4e-device-worker/internal/configuration"
+)
+
+// cooperativeSyncer stops as soon as its context is cancelled, simulating a
+// backend that reacts to graceful cancellation within TimeoutTERM.
+type cooperativeSyncer struct {
+	abortCalled bool
+}
+
+func (s *cooperativeSyncer) SyncPath(ctx context.Context, source, target string) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *cooperativeSyncer) Abort() error {
+	s.abortCalled = true
+	return nil
+}
+
+// stubbornSyncer ignores context cancellation and only stops once its
+// blockCh is closed, simulating a backend escalateShutdown has to forcibly
+// abort.
+type stubbornSyncer struct {
+	mu          sync.Mutex
+	abortCalled bool
+	blockCh     chan struct{}
+}
+
+func (s *stubbornSyncer) SyncPath(ctx context.Context, source, target string) error {
+	<-s.blockCh
+	return nil
+}
+
+func (s *stubbornSyncer) Abort() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.abortCalled = true
+	return nil
+}
+
+func (s *stubbornSyncer) wasAborted() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.abortCalled
+}
+
+func newShutdownTestMonitor(term, kill time.Duration) *Monitor {
+	return &Monitor{
+		config: configuration.NewManager(nil,
+			configuration.WithDataTransferTimeoutTERM(term),
+			configuration.WithDataTransferTimeoutKILL(kill),
+		),
+		schedules: make(map[string]*workloadSchedule),
+		inFlight:  make(map[string]*inFlightSync),
+	}
+}
+
+func TestEscalateShutdownReturnsOnceTheSyncStopsWithoutForcingAbort(t *testing.T) {
+	m := newShutdownTestMonitor(200*time.Millisecond, 200*time.Millisecond)
+
+	syncer := &cooperativeSyncer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	m.trackInFlight("wl", syncer, cancel, done)
+
+	go func() {
+		_ = syncer.SyncPath(ctx, "a", "b")
+		close(done)
+	}()
+
+	finished := make(chan struct{})
+	go func() {
+		m.escalateShutdown("wl")
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("escalateShutdown didn't return after the sync stopped")
+	}
+	if syncer.abortCalled {
+		t.Fatalf("expected escalateShutdown not to force an abort when the sync stopped within TimeoutTERM")
+	}
+}
+
+func TestEscalateShutdownAbortsAfterTimeoutTERMThenAbandonsAfterTimeoutKILL(t *testing.T) {
+	m := newShutdownTestMonitor(10*time.Millisecond, 10*time.Millisecond)
+
+	syncer := &stubbornSyncer{blockCh: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	m.trackInFlight("wl", syncer, cancel, done)
+	// done is never closed and blockCh only once the test returns, so the
+	// sync never stops on its own: escalateShutdown must abort and,
+	// eventually, abandon it.
+	defer close(syncer.blockCh)
+
+	finished := make(chan struct{})
+	go func() {
+		m.escalateShutdown("wl")
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatalf("escalateShutdown didn't abandon the sync after TimeoutKILL elapsed")
+	}
+	if !syncer.wasAborted() {
+		t.Fatalf("expected escalateShutdown to forcibly abort a sync that didn't stop within TimeoutTERM")
+	}
+}
+
+func TestEscalateShutdownIsANoOpWhenNoSyncIsInFlight(t *testing.T) {
+	m := newShutdownTestMonitor(10*time.Millisecond, 10*time.Millisecond)
+
+	finished := make(chan struct{})
+	go func() {
+		m.escalateShutdown("never-started")
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("expected escalateShutdown to return immediately when the workload isn't tracked as in-flight")
+	}
+}
@@ -0,0 +1,85 @@
+package datatransfer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jakub-dzon/k4e-operator/models"
+)
+
+// Syncer pushes the contents of a local path to a remote destination. Each
+// supported remote storage backend (S3, rsync, ...) provides its own
+// implementation and is selected at runtime based on the device's storage
+// configuration. ctx governs the call only: cancelling it asks the backend
+// to stop as soon as it reasonably can, it does not cancel any other call in
+// flight on the same Syncer.
+type Syncer interface {
+	SyncPath(ctx context.Context, source, target string) error
+}
+
+// Downloader is implemented by backends that can also pull remote content
+// down to the device. It's required to honour "download" and
+// "bidirectional" DataPaths; backends that only support pushing data don't
+// implement it.
+type Downloader interface {
+	Pull(ctx context.Context, source, target string) error
+}
+
+// Deleter is implemented by backends that can remove a previously-uploaded
+// remote path. It's used to prune blobs for files that disappeared from the
+// device between syncs, guarded by the PruneRemote setting.
+type Deleter interface {
+	DeletePath(target string) error
+}
+
+// Abortable lets a Syncer backend be forcibly stopped mid-transfer, e.g. by
+// killing a child process or closing an HTTP client. It's used to enforce
+// the escalation's forced-kill step when a workload is removed while a sync
+// to it is still running and hasn't reacted to graceful cancellation in
+// time.
+type Abortable interface {
+	Abort() error
+}
+
+// SyncerFactory builds a Syncer out of a device's storage configuration. It
+// returns an error if the configuration doesn't carry the settings the
+// backend needs.
+type SyncerFactory func(storage *models.Storage) (Syncer, error)
+
+var syncerRegistry = map[string]SyncerFactory{}
+
+// RegisterSyncer makes a backend available for selection by name. The
+// built-in s3 and rsync backends are registered from this package's init
+// function in backends.go.
+func RegisterSyncer(name string, factory SyncerFactory) {
+	syncerRegistry[name] = factory
+}
+
+// newSyncer resolves the backend configured in storage and builds a Syncer
+// for it.
+func newSyncer(storage *models.Storage) (Syncer, error) {
+	name, err := backendName(storage)
+	if err != nil {
+		return nil, err
+	}
+	factory, ok := syncerRegistry[name]
+	if !ok {
+		return nil, fmt.Errorf("no syncer registered for backend %q", name)
+	}
+	return factory(storage)
+}
+
+// backendName picks the storage backend configured for the device. Exactly
+// one backend is expected to be set at a time.
+func backendName(storage *models.Storage) (string, error) {
+	if storage == nil {
+		return "", fmt.Errorf("no storage configuration present")
+	}
+	if storage.S3 != nil {
+		return "s3", nil
+	}
+	if storage.Rsync != nil {
+		return "rsync", nil
+	}
+	return "", fmt.Errorf("storage configuration doesn't enable any known backend")
+}
@@ -0,0 +1,113 @@
+package datatransfer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jakub-dzon/k4e-operator/models"
+)
+
+// fakeSyncer is a minimal in-memory Syncer used across this package's
+// tests, so they don't need a real S3 bucket or rsync binary.
+type fakeSyncer struct {
+	syncedPaths []string
+	syncErr     error
+}
+
+func (f *fakeSyncer) SyncPath(ctx context.Context, source, target string) error {
+	if f.syncErr != nil {
+		return f.syncErr
+	}
+	f.syncedPaths = append(f.syncedPaths, source+"=>"+target)
+	return nil
+}
+
+// fakeBidirectionalSyncer additionally implements Downloader, copying bytes
+// onto disk so download-path tests can assert on real file content.
+type fakeBidirectionalSyncer struct {
+	fakeSyncer
+	pulled []string
+}
+
+func (f *fakeBidirectionalSyncer) Pull(ctx context.Context, source, target string) error {
+	f.pulled = append(f.pulled, target+"=>"+source)
+	return os.WriteFile(source, []byte("remote-data"), 0o644)
+}
+
+func TestBackendNameSelectsConfiguredBackend(t *testing.T) {
+	cases := []struct {
+		name    string
+		storage *models.Storage
+		want    string
+		wantErr bool
+	}{
+		{name: "nil storage", storage: nil, wantErr: true},
+		{name: "no backend configured", storage: &models.Storage{}, wantErr: true},
+		{name: "s3", storage: &models.Storage{S3: &models.S3Storage{}}, want: "s3"},
+		{name: "rsync", storage: &models.Storage{Rsync: &models.RsyncStorage{}}, want: "rsync"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := backendName(c.storage)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != c.want {
+				t.Fatalf("got backend %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestNewSyncerUsesTheRegisteredFactoryForTheConfiguredBackend(t *testing.T) {
+	fake := &fakeSyncer{}
+	original := syncerRegistry["s3"]
+	syncerRegistry["s3"] = func(storage *models.Storage) (Syncer, error) { return fake, nil }
+	defer func() { syncerRegistry["s3"] = original }()
+
+	syncer, err := newSyncer(&models.Storage{S3: &models.S3Storage{}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if syncer != fake {
+		t.Fatalf("expected newSyncer to return the registered factory's syncer")
+	}
+}
+
+func TestNewSyncerErrorsWhenNoFactoryIsRegisteredForTheBackend(t *testing.T) {
+	original := syncerRegistry["rsync"]
+	delete(syncerRegistry, "rsync")
+	defer func() { syncerRegistry["rsync"] = original }()
+
+	if _, err := newSyncer(&models.Storage{Rsync: &models.RsyncStorage{}}); err == nil {
+		t.Fatalf("expected an error for an unregistered backend")
+	}
+}
+
+func TestDownloaderPullsRemoteContentOntoDisk(t *testing.T) {
+	var syncer Syncer = &fakeBidirectionalSyncer{}
+	downloader, ok := syncer.(Downloader)
+	if !ok {
+		t.Fatalf("expected fakeBidirectionalSyncer to implement Downloader")
+	}
+
+	dest := filepath.Join(t.TempDir(), "f.txt")
+	if err := downloader.Pull(context.Background(), dest, "remote/f.txt"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != "remote-data" {
+		t.Fatalf("got content %q, want %q", data, "remote-data")
+	}
+}
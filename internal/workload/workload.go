@@ -0,0 +1,74 @@
+// Package workload tracks the workloads currently deployed to the device
+// and where each one's data lives on disk.
+package workload
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// Workload is a single workload the device is running.
+type Workload struct {
+	Name     string
+	HostPath string
+}
+
+// WorkloadManager tracks the workloads currently deployed to the device.
+type WorkloadManager struct {
+	stateDir string
+
+	mu        sync.RWMutex
+	workloads map[string]*Workload
+}
+
+// NewWorkloadManager creates a WorkloadManager that persists per-workload
+// state under stateDir.
+func NewWorkloadManager(stateDir string) *WorkloadManager {
+	return &WorkloadManager{
+		stateDir:  stateDir,
+		workloads: make(map[string]*Workload),
+	}
+}
+
+// AddWorkload registers a running workload and the host path its exported
+// data lives under.
+func (w *WorkloadManager) AddWorkload(name, hostPath string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.workloads[name] = &Workload{Name: name, HostPath: hostPath}
+}
+
+// RemoveWorkload unregisters a workload that's no longer deployed.
+func (w *WorkloadManager) RemoveWorkload(name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.workloads, name)
+}
+
+// ListWorkloads returns the workloads currently registered.
+func (w *WorkloadManager) ListWorkloads() ([]*Workload, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	result := make([]*Workload, 0, len(w.workloads))
+	for _, wl := range w.workloads {
+		result = append(result, wl)
+	}
+	return result, nil
+}
+
+// GetExportedHostPath returns the host path workloadName's exported data
+// lives under, or "" if the workload isn't registered.
+func (w *WorkloadManager) GetExportedHostPath(workloadName string) string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if wl, ok := w.workloads[workloadName]; ok {
+		return wl.HostPath
+	}
+	return ""
+}
+
+// GetWorkloadStateDir returns the directory workloadName's own state (e.g.
+// sync manifests) should be persisted under.
+func (w *WorkloadManager) GetWorkloadStateDir(workloadName string) string {
+	return filepath.Join(w.stateDir, workloadName)
+}